@@ -0,0 +1,48 @@
+/*
+Copyright 2025 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+//go:build linux
+
+package translator
+
+import (
+	goselinux "github.com/opencontainers/selinux/go-selinux"
+)
+
+// parseSELinuxContext parses label (a "user:role:type:level" SELinux label, as produced by
+// SELinuxOptionsToFileLabel) into a seLinuxLabel, using opencontainers/selinux to split it, then
+// this package's own validateSELinuxLabelParts to actually reject malformed input - go-selinux's
+// NewContext only checks that the label splits into 4 ':'-separated fields and otherwise accepts
+// anything, so an invalid MCS range, illegal characters or a stray extra ':' in the level would
+// otherwise pass through uncaught.
+//
+// On error, the best-effort result of splitSELinuxLabel is still returned alongside the error, so
+// callers like Conflicts that want to keep comparing a malformed label permissively rather than
+// fail closed can do so.
+func parseSELinuxContext(label string) (seLinuxLabel, error) {
+	if label == "" {
+		return seLinuxLabel{}, nil
+	}
+	ctx, err := goselinux.NewContext(label)
+	if err != nil {
+		return splitSELinuxLabel(label), err
+	}
+	parsed := seLinuxLabel{User: ctx["user"], Role: ctx["role"], Type: ctx["type"], Level: ctx["level"]}
+	if err := validateSELinuxLabelParts(parsed); err != nil {
+		return parsed, err
+	}
+	return parsed, nil
+}
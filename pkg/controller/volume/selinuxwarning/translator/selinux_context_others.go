@@ -0,0 +1,36 @@
+/*
+Copyright 2025 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+//go:build !linux
+
+package translator
+
+// parseSELinuxContext is the non-linux stub: opencontainers/selinux only builds on linux, and KCM
+// is expected to run there, but still needs to compile on darwin/windows. Without the real
+// library to split the label against, fall back to the same permissive colon-splitting this
+// package used everywhere before, but still run this package's own validateSELinuxLabelParts, so
+// ValidateSELinuxOptions still catches an invalid MCS range or illegal characters on these
+// platforms - it only loses the extra cross-check go-selinux's grammar would have provided.
+func parseSELinuxContext(label string) (seLinuxLabel, error) {
+	if label == "" {
+		return seLinuxLabel{}, nil
+	}
+	parsed := splitSELinuxLabel(label)
+	if err := validateSELinuxLabelParts(parsed); err != nil {
+		return parsed, err
+	}
+	return parsed, nil
+}
@@ -17,7 +17,11 @@ limitations under the License.
 package translator
 
 import (
+	"fmt"
+	"regexp"
+	"strconv"
 	"strings"
+	"sync"
 
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/kubernetes/pkg/volume/util"
@@ -28,33 +32,187 @@ import (
 // KCM often runs as a container and cannot access /etc/selinux on the host. Even if it could, KCM can run on a different distro
 // than the actual worker nodes.
 // Therefore do not even try to file the defaults, use only fields filed in the provided SELinuxOptions.
-type ControllerSELinuxTranslator struct{}
+//
+// KCM does know, however, which default SELinux type a given RuntimeClass maps to (e.g. KVM-isolated
+// sandboxes use a process/file type different from the plain container_t/container_file_t), because
+// that mapping is static configuration rather than a node-local fact. runtimeClassDefaultType carries
+// that mapping so it can be consulted when the user did not set opts.Type explicitly.
+//
+// Separately, container runtimes such as Podman also give init containers their own default type
+// (distinct from both the plain and the RuntimeClass-specific one), because an init container runs
+// to completion before the rest of the Pod starts and is commonly isolated from it. initContainerDefaultType
+// carries that default so it can be consulted the same way, independent of the Pod's RuntimeClass.
+type ControllerSELinuxTranslator struct {
+	// runtimeClassDefaultType maps a RuntimeClass name to the SELinux type used to fill in
+	// SELinuxOptions.Type when the caller left it empty. It is nil (no defaulting) unless
+	// populated via NewControllerSELinuxTranslator.
+	runtimeClassDefaultType map[string]string
+
+	// initContainerDefaultType is the SELinux type used to fill in SELinuxOptions.Type for an
+	// init container when the caller left it empty, regardless of the Pod's RuntimeClass. It is
+	// "" (no defaulting) unless populated via NewControllerSELinuxTranslator.
+	initContainerDefaultType string
+
+	nodeDefaultsLock sync.RWMutex
+	// nodeDefaults holds the resolved SELinux user/role/type defaults reported by each node,
+	// keyed by node name. It is populated by WithNodeDefaults and consulted by ConflictsOnNode.
+	nodeDefaults map[string]SELinuxDefaults
+}
+
+// SELinuxDefaults are the user/role/type a node's SELinux policy would fill in for a Pod that
+// does not set the corresponding SELinuxOptions field. Kubelet resolves these from
+// /etc/selinux/<policy>/contexts/* and publishes them so KCM, which cannot read that file itself,
+// can use them to compare Pods that are known to land on the same node.
+type SELinuxDefaults struct {
+	User string
+	Role string
+	Type string
+}
+
+// seLinuxLabel is a SELinux "user:role:type:level" label split into its four components. It is
+// the common currency between parseSELinuxContext (which produces one from a label string,
+// validating as it goes) and the code that builds or compares labels, so that Conflicts,
+// SELinuxOptionsToFileLabelForClass and ValidateSELinuxOptions all agree on what a label's parts
+// are instead of each re-deriving them with their own strings.SplitN call.
+type seLinuxLabel struct {
+	User, Role, Type, Level string
+}
+
+// String renders l back into a "user:role:type:level" label, or "" if all four components are
+// empty (matching the nil-SELinuxOptions behavior used throughout this package).
+func (l seLinuxLabel) String() string {
+	if l == (seLinuxLabel{}) {
+		return ""
+	}
+	return strings.Join([]string{l.User, l.Role, l.Type, l.Level}, ":")
+}
+
+// splitSELinuxLabel splits label into its four ':'-separated components without any validation,
+// padding missing trailing components with "". It is the permissive fallback parseSELinuxContext
+// uses on platforms or inputs where stricter parsing isn't available.
+func splitSELinuxLabel(label string) seLinuxLabel {
+	parts := strings.SplitN(label, ":", 4)
+	for len(parts) < 4 {
+		parts = append(parts, "")
+	}
+	return seLinuxLabel{User: parts[0], Role: parts[1], Type: parts[2], Level: parts[3]}
+}
+
+// validSELinuxIdentifier matches a single SELinux user/role/type identifier, e.g. "system_u" or
+// "container_t": letters, digits and underscores, as used throughout the bundled policies.
+var validSELinuxIdentifier = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+
+// validSELinuxLevel matches a well-formed MCS/MLS level: a "sensitivity[:categories]" part,
+// optionally followed by "-sensitivity[:categories]" for a range, where categories is a
+// comma-separated list of "cN" or "cN.cM" entries. This is stricter than go-selinux's NewContext,
+// which only checks that a label splits into 4 ':'-separated fields and otherwise accepts
+// anything in the level - including a malformed range like "s0:cfoo" or a stray extra ':'.
+var validSELinuxLevel = regexp.MustCompile(`^s\d+(:c\d+(\.c\d+)?(,c\d+(\.c\d+)?)*)?(-s\d+(:c\d+(\.c\d+)?(,c\d+(\.c\d+)?)*)?)?$`)
+
+// validateSELinuxLabelParts returns an error if any non-empty component of l does not look like a
+// well-formed SELinux label component.
+func validateSELinuxLabelParts(l seLinuxLabel) error {
+	for _, part := range []struct{ name, value string }{
+		{"user", l.User},
+		{"role", l.Role},
+		{"type", l.Type},
+	} {
+		if part.value != "" && !validSELinuxIdentifier.MatchString(part.value) {
+			return fmt.Errorf("invalid SELinux %s %q", part.name, part.value)
+		}
+	}
+	if l.Level != "" && !validSELinuxLevel.MatchString(l.Level) {
+		return fmt.Errorf("invalid SELinux level %q", l.Level)
+	}
+	return nil
+}
 
 var _ util.SELinuxLabelTranslator = &ControllerSELinuxTranslator{}
 
+// NewControllerSELinuxTranslator returns a ControllerSELinuxTranslator that defaults the SELinux
+// type of a Pod without an explicit SELinuxOptions.Type according to runtimeClassDefaultType, a
+// mapping of RuntimeClass name to the SELinux type used by that class (e.g. populated from KCM
+// flags or a ConfigMap watch), and initContainerDefaultType, the SELinux type used for init
+// containers regardless of RuntimeClass. A nil or empty runtimeClassDefaultType, or an empty
+// initContainerDefaultType, disables the corresponding defaulting, matching the zero value.
+func NewControllerSELinuxTranslator(runtimeClassDefaultType map[string]string, initContainerDefaultType string) *ControllerSELinuxTranslator {
+	return &ControllerSELinuxTranslator{
+		runtimeClassDefaultType:  runtimeClassDefaultType,
+		initContainerDefaultType: initContainerDefaultType,
+	}
+}
+
 func (c *ControllerSELinuxTranslator) SELinuxEnabled() bool {
 	// The controller must have been explicitly enabled, so expect that all nodes have SELinux enabled.
 	return true
 }
 
 func (c *ControllerSELinuxTranslator) SELinuxOptionsToFileLabel(opts *v1.SELinuxOptions) (string, error) {
+	return c.SELinuxOptionsToFileLabelForClass(opts, "", false)
+}
+
+// SELinuxOptionsToFileLabelForClass is like SELinuxOptionsToFileLabel, but additionally takes the
+// RuntimeClass name of the Pod the options belong to and whether the options are for an init
+// container. opts may be nil, meaning the Pod set no SELinuxOptions at all: that is treated the
+// same as an empty SELinuxOptions{} rather than short-circuited, so the defaulting below still
+// applies to the (overwhelmingly common) case of a Pod that doesn't set SELinuxOptions.Type.
+//
+// When the resulting Type is empty, the default type is filled in instead of leaving the
+// component empty: an init container defaults to initContainerDefaultType (when set); any other
+// container, or an init container when initContainerDefaultType is "", defaults to
+// runtimeClassDefaultType[runtimeClass] (when runtimeClass is a known key).
+// isInit deliberately takes precedence over runtimeClass rather than combining with it - when
+// initContainerDefaultType is set, an init container always gets it, even under a kata/KVM
+// RuntimeClass - because KCM only has a single type per axis to offer; a combined "KVM init
+// container" type would need a second map keyed on (runtimeClass, isInit) that nothing currently
+// populates. This means two init containers isolated by different RuntimeClasses get the same type
+// and are not flagged as conflicting with each other on account of it.
+//
+// This lets e.g. a kata/KVM RuntimeClass, or an init container, default to its own process/file
+// type instead of being treated as "incomparable" (and therefore never conflicting) with plain
+// container_t Pods that happen to share the same MCS level.
+func (c *ControllerSELinuxTranslator) SELinuxOptionsToFileLabelForClass(opts *v1.SELinuxOptions, runtimeClass string, isInit bool) (string, error) {
 	if opts == nil {
-		return "", nil
+		opts = &v1.SELinuxOptions{}
+	}
+	seType := opts.Type
+	if seType == "" {
+		if isInit && c.initContainerDefaultType != "" {
+			seType = c.initContainerDefaultType
+		} else {
+			seType = c.runtimeClassDefaultType[runtimeClass]
+		}
 	}
 	// kube-controller-manager cannot access SELinux defaults in /etc/selinux on nodes.
-	// Just concatenate the existing fields and do not try to default the missing ones.
-	parts := []string{
-		opts.User,
-		opts.Role,
-		opts.Type,
-		opts.Level,
+	// Just concatenate the existing fields and do not try to default the missing ones,
+	// except for the type, which may be known from the RuntimeClass or init container above.
+	label := seLinuxLabel{
+		User:  opts.User,
+		Role:  opts.Role,
+		Type:  seType,
+		Level: opts.Level,
+	}
+	return label.String(), nil
+}
+
+// ValidateSELinuxOptions returns an error if opts cannot be turned into a well-formed SELinux
+// label. It is backed by parseSELinuxContext (opencontainers/selinux on linux, plus this package's
+// own stricter structural checks on every platform), so it catches malformed input - an invalid
+// MCS range, illegal characters, too many ':'-separated segments - up front. Callers such as
+// admission or CSIDriver/PVC validation can use this to reject obviously broken SELinuxOptions
+// early, instead of the value only surfacing later as a silently "incomparable" label in Conflicts.
+func ValidateSELinuxOptions(opts *v1.SELinuxOptions) error {
+	if opts == nil {
+		return nil
+	}
+	label := seLinuxLabel{User: opts.User, Role: opts.Role, Type: opts.Type, Level: opts.Level}.String()
+	if label == "" {
+		return nil
 	}
-	label := strings.Join(parts, ":")
-	if label == ":::" {
-		// Empty SELinuxOptions should have the same behavior as nil
-		return "", nil
+	if _, err := parseSELinuxContext(label); err != nil {
+		return fmt.Errorf("invalid SELinux label %q: %w", label, err)
 	}
-	return label, nil
+	return nil
 }
 
 // Conflicts returns true if two SELinux labels conflict.
@@ -67,31 +225,230 @@ func (c *ControllerSELinuxTranslator) SELinuxOptionsToFileLabel(opts *v1.SELinux
 // because the node that will run such a Pod may expand "":::s0:c1,c2" to "system_u:system_r:container_t:s0:c1,c2".
 // However, "system_u:system_r:container_t:s0:c1,c2" *does* conflict with ":::s0:c98,c99".
 func (c *ControllerSELinuxTranslator) Conflicts(labelA, labelB string) bool {
-	partsA := strings.SplitN(labelA, ":", 4)
-	partsB := strings.SplitN(labelB, ":", 4)
+	// parseSELinuxContext's error is intentionally ignored here: labelA/labelB are expected to
+	// already be well-formed (produced by SELinuxOptionsToFileLabel), and even when one isn't,
+	// parseSELinuxContext still returns its best-effort, permissively-split components, which is
+	// exactly the fallback this function wants rather than failing closed.
+	a, _ := parseSELinuxContext(labelA)
+	b, _ := parseSELinuxContext(labelB)
+
+	if componentConflicts(a.User, b.User) {
+		return true
+	}
+	if componentConflicts(a.Role, b.Role) {
+		return true
+	}
+	if componentConflicts(a.Type, b.Type) {
+		return true
+	}
+	// The level is not a plain string: the same set of categories can be written in different
+	// order or as a range, e.g. "s0:c1,c2" == "s0:c2,c1" == "s0:c1.c2". Compare it with
+	// set-aware semantics instead of strict string equality.
+	return mcsLevelsConflict(a.Level, b.Level)
+}
+
+// componentConflicts reports whether two user/role/type label components conflict: equal, or
+// either side empty (incomparable), is not a conflict; anything else is.
+func componentConflicts(a, b string) bool {
+	if a == b {
+		return false
+	}
+	if a == "" || b == "" {
+		// incomparable part, no conflict
+		return false
+	}
+	return true
+}
+
+// WithNodeDefaults records the resolved SELinux user/role/type defaults reported by nodeName, so
+// that a later ConflictsOnNode call for that node can fill in empty label components before
+// comparing them instead of treating them as incomparable. It is safe to call concurrently and
+// may be called repeatedly for the same node as its reported defaults change.
+func (c *ControllerSELinuxTranslator) WithNodeDefaults(nodeName string, defaults SELinuxDefaults) {
+	c.nodeDefaultsLock.Lock()
+	defer c.nodeDefaultsLock.Unlock()
+	if c.nodeDefaults == nil {
+		c.nodeDefaults = map[string]SELinuxDefaults{}
+	}
+	c.nodeDefaults[nodeName] = defaults
+}
+
+// ConflictsOnNode is like Conflicts, but additionally takes the name of the node both labels'
+// Pods are bound to. When the node's SELinux defaults are known (reported via WithNodeDefaults),
+// empty user/role/type components of each label are filled in with those defaults before the
+// comparison, so that e.g. two Pods with labels ":::s0:c1,c2" and ":::s0:c1,c2" on a node whose
+// defaults resolve both to the same concrete label are correctly seen as equal, while still
+// allowing genuinely different partially-specified labels to conflict. When nodeName's defaults
+// are unknown, this behaves exactly like Conflicts.
+func (c *ControllerSELinuxTranslator) ConflictsOnNode(labelA, labelB, nodeName string) bool {
+	c.nodeDefaultsLock.RLock()
+	defaults, ok := c.nodeDefaults[nodeName]
+	c.nodeDefaultsLock.RUnlock()
+	if !ok {
+		return c.Conflicts(labelA, labelB)
+	}
+	return c.Conflicts(applyNodeDefaults(labelA, defaults), applyNodeDefaults(labelB, defaults))
+}
+
+// applyNodeDefaults fills the empty user/role/type components of label with the corresponding
+// fields of defaults. The level is never defaulted: it is Pod-specific (assigned per Pod/volume,
+// not derived from the node's static SELinux policy), so an empty level still means "unspecified".
+func applyNodeDefaults(label string, defaults SELinuxDefaults) string {
+	if label == "" {
+		return label
+	}
+	parsed := splitSELinuxLabel(label)
+	if parsed.User == "" {
+		parsed.User = defaults.User
+	}
+	if parsed.Role == "" {
+		parsed.Role = defaults.Role
+	}
+	if parsed.Type == "" {
+		parsed.Type = defaults.Type
+	}
+	return strings.Join([]string{parsed.User, parsed.Role, parsed.Type, parsed.Level}, ":")
+}
+
+// mcsLevel is the parsed, canonical form of a SELinux MCS level, e.g.
+// "s0:c1,c2" or "s0-s0:c0.c1023". Sensitivity is kept as a string ("s0"),
+// because sensitivities can differ in more ways than a simple integer range,
+// while categories are expanded into a set so that order and range notation
+// don't matter for comparison.
+type mcsLevel struct {
+	lowSensitivity, highSensitivity string
+	lowCategories, highCategories   map[int]struct{}
+}
 
-	// Reorder, so partsA is always longer than partsB
-	if len(partsA) < len(partsB) {
-		partsB, partsA = partsA, partsB
+// parseMCSLevel parses a (possibly empty or partially specified) MCS level
+// string into its canonical form. It never errors: sensitivities are kept
+// verbatim, and category entries it cannot parse are dropped (see
+// parseCategories), matching the permissive behavior of the rest of this
+// translator.
+func parseMCSLevel(level string) mcsLevel {
+	if level == "" {
+		return mcsLevel{}
 	}
 
-	for len(partsB) < len(partsA) {
-		partsB = append(partsB, "")
+	// A level is either "low" or "low-high", where "low"/"high" are each
+	// "sensitivity[:categories]".
+	low, high, hasRange := strings.Cut(level, "-")
+
+	lowSensitivity, lowCategories := parseSensitivityAndCategories(low)
+	parsed := mcsLevel{
+		lowSensitivity: lowSensitivity,
+		lowCategories:  lowCategories,
+	}
+	if hasRange {
+		parsed.highSensitivity, parsed.highCategories = parseSensitivityAndCategories(high)
+	} else {
+		// A level with no explicit "-high" part is SELinux shorthand for the range low-low
+		// (e.g. "s0:c1,c2" means "s0-s0:c1,c2"). Mirror low into high so this is compared the
+		// same way as an equivalent label that does spell out the range, instead of leaving
+		// high empty and therefore incomparable against a label that specifies a different,
+		// explicit range such as "s0-s1:c1,c2".
+		parsed.highSensitivity = lowSensitivity
+		parsed.highCategories = lowCategories
+	}
+	return parsed
+}
+
+// parseSensitivityAndCategories splits "sensitivity:categories" (or just
+// "sensitivity") and expands the categories expression into a set of
+// category numbers.
+func parseSensitivityAndCategories(part string) (string, map[int]struct{}) {
+	sensitivity, categoriesExpr, _ := strings.Cut(part, ":")
+	return sensitivity, parseCategories(categoriesExpr)
+}
+
+// parseCategories expands a comma-separated categories expression, such as
+// "c1,c2" or "c0.c1023", into the canonical set of category numbers it
+// represents. Entries that cannot be parsed as a category are ignored, so a
+// malformed level degrades to fewer constraints rather than an error.
+func parseCategories(expr string) map[int]struct{} {
+	if expr == "" {
+		return nil
 	}
-	for i := range partsA {
-		if partsA[i] == partsB[i] {
+
+	categories := map[int]struct{}{}
+	for _, entry := range strings.Split(expr, ",") {
+		from, to, isRange := strings.Cut(entry, ".")
+		fromNum, ok := parseCategory(from)
+		if !ok {
 			continue
 		}
-		if partsA[i] == "" {
-			// incomparable part, no conflict
+		if !isRange {
+			categories[fromNum] = struct{}{}
 			continue
 		}
-		if partsB[i] == "" {
-			// incomparable part, no conflict
+		toNum, ok := parseCategory(to)
+		if !ok {
 			continue
 		}
-		// Parts are not equal and neither of them is "" -> conflict
+		for n := fromNum; n <= toNum; n++ {
+			categories[n] = struct{}{}
+		}
+	}
+	return categories
+}
+
+// parseCategory parses a single category such as "c1023" into its number.
+func parseCategory(s string) (int, bool) {
+	if !strings.HasPrefix(s, "c") {
+		return 0, false
+	}
+	n, err := strconv.Atoi(s[1:])
+	if err != nil {
+		return 0, false
+	}
+	return n, true
+}
+
+// mcsLevelsConflict reports whether two MCS level strings conflict, using
+// set-aware semantics: sensitivities and category sets are only compared
+// when both sides specify them, and categories conflict when the sets
+// differ, regardless of the order or range notation used to write them.
+func mcsLevelsConflict(levelA, levelB string) bool {
+	if levelA == "" || levelB == "" {
+		// incomparable, no conflict
+		return false
+	}
+	a := parseMCSLevel(levelA)
+	b := parseMCSLevel(levelB)
+
+	if sensitivitiesConflict(a.lowSensitivity, b.lowSensitivity) {
+		return true
+	}
+	if sensitivitiesConflict(a.highSensitivity, b.highSensitivity) {
+		return true
+	}
+	if categorySetsConflict(a.lowCategories, b.lowCategories) {
+		return true
+	}
+	if categorySetsConflict(a.highCategories, b.highCategories) {
 		return true
 	}
 	return false
 }
+
+func sensitivitiesConflict(a, b string) bool {
+	if a == "" || b == "" {
+		return false
+	}
+	return a != b
+}
+
+func categorySetsConflict(a, b map[int]struct{}) bool {
+	if len(a) == 0 || len(b) == 0 {
+		return false
+	}
+	if len(a) != len(b) {
+		return true
+	}
+	for c := range a {
+		if _, ok := b[c]; !ok {
+			return true
+		}
+	}
+	return false
+}
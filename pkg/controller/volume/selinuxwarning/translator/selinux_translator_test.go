@@ -0,0 +1,363 @@
+/*
+Copyright 2025 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package translator
+
+import (
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+func TestConflicts(t *testing.T) {
+	tests := []struct {
+		name         string
+		labelA       string
+		labelB       string
+		wantConflict bool
+	}{
+		{
+			name:         "identical labels",
+			labelA:       "system_u:system_r:container_t:s0:c1,c2",
+			labelB:       "system_u:system_r:container_t:s0:c1,c2",
+			wantConflict: false,
+		},
+		{
+			name:         "reversed category order is not a conflict",
+			labelA:       "system_u:system_r:container_t:s0:c1,c2",
+			labelB:       "system_u:system_r:container_t:s0:c2,c1",
+			wantConflict: false,
+		},
+		{
+			name:         "category range equals its expansion",
+			labelA:       "system_u:system_r:container_t:s0:c1,c2,c3",
+			labelB:       "system_u:system_r:container_t:s0:c1.c3",
+			wantConflict: false,
+		},
+		{
+			name:         "different category sets conflict",
+			labelA:       "system_u:system_r:container_t:s0:c1,c2",
+			labelB:       "system_u:system_r:container_t:s0:c1,c3",
+			wantConflict: true,
+		},
+		{
+			name:         "empty level component is incomparable",
+			labelA:       "system_u:system_r:container_t:s0:c1,c2",
+			labelB:       ":::",
+			wantConflict: false,
+		},
+		{
+			name:         "empty label does not conflict with anything",
+			labelA:       "system_u:system_r:container_t:s0:c1,c2",
+			labelB:       "",
+			wantConflict: false,
+		},
+		{
+			name:         "different type conflicts",
+			labelA:       "system_u:system_r:container_t:s0:c1,c2",
+			labelB:       "system_u:system_r:spc_t:s0:c1,c2",
+			wantConflict: true,
+		},
+		{
+			name:         "different sensitivity range conflicts",
+			labelA:       "system_u:system_r:container_t:s0-s0:c1,c2",
+			labelB:       "system_u:system_r:container_t:s0-s1:c1,c2",
+			wantConflict: true,
+		},
+		{
+			name:         "sensitivity range with reordered categories is not a conflict",
+			labelA:       "system_u:system_r:container_t:s0-s0:c1,c2",
+			labelB:       "system_u:system_r:container_t:s0-s0:c2,c1",
+			wantConflict: false,
+		},
+		{
+			name:         "implicit single-sensitivity range conflicts with an explicit different range",
+			labelA:       "system_u:system_r:container_t:s0:c1,c2",
+			labelB:       "system_u:system_r:container_t:s0-s1:c1,c2",
+			wantConflict: true,
+		},
+		{
+			name:         "partially specified label only compares known components",
+			labelA:       "system_u:system_r:container_t:s0:c1,c2",
+			labelB:       ":::s0:c2,c1",
+			wantConflict: false,
+		},
+		{
+			name:         "partially specified label still catches category mismatch",
+			labelA:       "system_u:system_r:container_t:s0:c1,c2",
+			labelB:       ":::s0:c98,c99",
+			wantConflict: true,
+		},
+	}
+
+	translator := &ControllerSELinuxTranslator{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := translator.Conflicts(tt.labelA, tt.labelB); got != tt.wantConflict {
+				t.Errorf("Conflicts(%q, %q) = %v, want %v", tt.labelA, tt.labelB, got, tt.wantConflict)
+			}
+			// Conflicts must be symmetric.
+			if got := translator.Conflicts(tt.labelB, tt.labelA); got != tt.wantConflict {
+				t.Errorf("Conflicts(%q, %q) = %v, want %v", tt.labelB, tt.labelA, got, tt.wantConflict)
+			}
+		})
+	}
+}
+
+func TestParseCategories(t *testing.T) {
+	tests := []struct {
+		name string
+		expr string
+		want map[int]struct{}
+	}{
+		{
+			name: "empty",
+			expr: "",
+			want: nil,
+		},
+		{
+			name: "single category",
+			expr: "c1",
+			want: map[int]struct{}{1: {}},
+		},
+		{
+			name: "comma separated list",
+			expr: "c1,c2,c5",
+			want: map[int]struct{}{1: {}, 2: {}, 5: {}},
+		},
+		{
+			name: "range",
+			expr: "c0.c3",
+			want: map[int]struct{}{0: {}, 1: {}, 2: {}, 3: {}},
+		},
+		{
+			name: "full category range",
+			expr: "c0.c1023",
+			want: rangeSet(0, 1023),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := parseCategories(tt.expr)
+			if len(got) != len(tt.want) {
+				t.Fatalf("parseCategories(%q) = %v, want %v", tt.expr, got, tt.want)
+			}
+			for c := range tt.want {
+				if _, ok := got[c]; !ok {
+					t.Errorf("parseCategories(%q) missing category c%d", tt.expr, c)
+				}
+			}
+		})
+	}
+}
+
+func TestSELinuxOptionsToFileLabelForClass(t *testing.T) {
+	translator := NewControllerSELinuxTranslator(map[string]string{
+		"kata": "kata_container_t",
+	}, "init_container_t")
+
+	tests := []struct {
+		name         string
+		opts         *v1.SELinuxOptions
+		runtimeClass string
+		isInit       bool
+		want         string
+	}{
+		{
+			name:         "nil options with no runtime class behaves like empty options",
+			opts:         nil,
+			want:         "",
+		},
+		{
+			name:         "nil options still get the runtime class type defaulted",
+			opts:         nil,
+			runtimeClass: "kata",
+			want:         "::kata_container_t:",
+		},
+		{
+			name:         "nil options for an init container still get the init type defaulted",
+			opts:         nil,
+			isInit:       true,
+			want:         "::init_container_t:",
+		},
+		{
+			name:         "explicit type is never overridden",
+			opts:         &v1.SELinuxOptions{User: "system_u", Role: "system_r", Type: "container_t", Level: "s0:c1,c2"},
+			runtimeClass: "kata",
+			isInit:       true,
+			want:         "system_u:system_r:container_t:s0:c1,c2",
+		},
+		{
+			name:         "known runtime class defaults the empty type",
+			opts:         &v1.SELinuxOptions{User: "system_u", Role: "system_r", Level: "s0:c1,c2"},
+			runtimeClass: "kata",
+			want:         "system_u:system_r:kata_container_t:s0:c1,c2",
+		},
+		{
+			name:         "unknown runtime class leaves the type empty",
+			opts:         &v1.SELinuxOptions{User: "system_u", Role: "system_r", Level: "s0:c1,c2"},
+			runtimeClass: "gvisor",
+			want:         "system_u:system_r::s0:c1,c2",
+		},
+		{
+			name:         "no runtime class behaves like SELinuxOptionsToFileLabel",
+			opts:         &v1.SELinuxOptions{User: "system_u", Role: "system_r", Level: "s0:c1,c2"},
+			runtimeClass: "",
+			want:         "system_u:system_r::s0:c1,c2",
+		},
+		{
+			name:         "init container defaults to its own type regardless of runtime class",
+			opts:         &v1.SELinuxOptions{User: "system_u", Role: "system_r", Level: "s0:c1,c2"},
+			runtimeClass: "kata",
+			isInit:       true,
+			want:         "system_u:system_r:init_container_t:s0:c1,c2",
+		},
+		{
+			name:         "init container with no runtime class still defaults to the init type",
+			opts:         &v1.SELinuxOptions{User: "system_u", Role: "system_r", Level: "s0:c1,c2"},
+			isInit:       true,
+			want:         "system_u:system_r:init_container_t:s0:c1,c2",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := translator.SELinuxOptionsToFileLabelForClass(tt.opts, tt.runtimeClass, tt.isInit)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("SELinuxOptionsToFileLabelForClass(%+v, %q, %v) = %q, want %q", tt.opts, tt.runtimeClass, tt.isInit, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConflictsOnNode(t *testing.T) {
+	translator := &ControllerSELinuxTranslator{}
+	translator.WithNodeDefaults("node-with-defaults", SELinuxDefaults{
+		User: "system_u",
+		Role: "system_r",
+		Type: "container_file_t",
+	})
+
+	tests := []struct {
+		name         string
+		labelA       string
+		labelB       string
+		nodeName     string
+		wantConflict bool
+	}{
+		{
+			name:         "unknown node falls back to Conflicts",
+			labelA:       ":::s0:c1,c2",
+			labelB:       ":::s0:c98,c99",
+			nodeName:     "node-without-defaults",
+			wantConflict: true,
+		},
+		{
+			name:         "known node defaults make identical partial labels equal",
+			labelA:       ":::s0:c1,c2",
+			labelB:       ":::s0:c1,c2",
+			nodeName:     "node-with-defaults",
+			wantConflict: false,
+		},
+		{
+			name:         "known node defaults still catch a real category conflict",
+			labelA:       ":::s0:c1,c2",
+			labelB:       ":::s0:c98,c99",
+			nodeName:     "node-with-defaults",
+			wantConflict: true,
+		},
+		{
+			name:         "known node defaults do not mask an explicit different type",
+			labelA:       ":::s0:c1,c2",
+			labelB:       "system_u:system_r:spc_t:s0:c1,c2",
+			nodeName:     "node-with-defaults",
+			wantConflict: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := translator.ConflictsOnNode(tt.labelA, tt.labelB, tt.nodeName); got != tt.wantConflict {
+				t.Errorf("ConflictsOnNode(%q, %q, %q) = %v, want %v", tt.labelA, tt.labelB, tt.nodeName, got, tt.wantConflict)
+			}
+		})
+	}
+}
+
+func TestValidateSELinuxOptions(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    *v1.SELinuxOptions
+		wantErr bool
+	}{
+		{
+			name:    "nil options",
+			opts:    nil,
+			wantErr: false,
+		},
+		{
+			name:    "empty options",
+			opts:    &v1.SELinuxOptions{},
+			wantErr: false,
+		},
+		{
+			name:    "well-formed label",
+			opts:    &v1.SELinuxOptions{User: "system_u", Role: "system_r", Type: "container_t", Level: "s0:c1,c2"},
+			wantErr: false,
+		},
+		{
+			name:    "partially specified label",
+			opts:    &v1.SELinuxOptions{Level: "s0:c1,c2"},
+			wantErr: false,
+		},
+		{
+			name:    "malformed MCS range",
+			opts:    &v1.SELinuxOptions{User: "system_u", Role: "system_r", Type: "container_t", Level: "s0:cfoo"},
+			wantErr: true,
+		},
+		{
+			name:    "stray extra colon in the level",
+			opts:    &v1.SELinuxOptions{User: "system_u", Role: "system_r", Type: "container_t", Level: "s0:c1,c2:extra"},
+			wantErr: true,
+		},
+		{
+			name:    "illegal character in type",
+			opts:    &v1.SELinuxOptions{User: "system_u", Role: "system_r", Type: "container_t!", Level: "s0:c1,c2"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateSELinuxOptions(tt.opts)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateSELinuxOptions(%+v) error = %v, wantErr %v", tt.opts, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func rangeSet(from, to int) map[int]struct{} {
+	set := map[int]struct{}{}
+	for i := from; i <= to; i++ {
+		set[i] = struct{}{}
+	}
+	return set
+}